@@ -0,0 +1,60 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+var computeDevicePattern = regexp.MustCompile(`^nvidia[0-9]+$`)
+
+// discoverComputeDevices returns the physical GPU IDs (e.g. "nvidia0")
+// present under devRoot, sorted for a deterministic device list.
+func discoverComputeDevices(devRoot string) ([]string, error) {
+	entries, err := os.ReadDir(devRoot)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list compute devices in %s: %v", devRoot, err)
+	}
+
+	var devices []string
+	for _, entry := range entries {
+		if computeDevicePattern.MatchString(entry.Name()) {
+			devices = append(devices, entry.Name())
+		}
+	}
+	sort.Strings(devices)
+	return devices, nil
+}
+
+// buildVirtualDevices expands each physical device ID into vgpusPerDevice
+// virtual device IDs (e.g. "nvidia0" -> "nvidia0/vgpu0", "nvidia0/vgpu1"),
+// each backed by the physical device's /dev node, for the CDI spec and the
+// kubelet device list.
+func buildVirtualDevices(physicalDeviceIDs []string, vgpusPerDevice int) map[string][]string {
+	devices := make(map[string][]string, len(physicalDeviceIDs)*vgpusPerDevice)
+	for _, physicalDeviceID := range physicalDeviceIDs {
+		for i := 0; i < vgpusPerDevice; i++ {
+			virtualDeviceID := fmt.Sprintf("%s/vgpu%d", physicalDeviceID, i)
+			devices[virtualDeviceID] = []string{fmt.Sprintf("/dev/%s", physicalDeviceID)}
+		}
+	}
+	return devices
+}
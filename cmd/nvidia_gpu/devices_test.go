@@ -0,0 +1,63 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiscoverComputeDevices(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"nvidia0", "nvidia1", "nvidiactl", "nvidia-uvm"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatalf("failed to create fake device node: %v", err)
+		}
+	}
+
+	got, err := discoverComputeDevices(root)
+	if err != nil {
+		t.Fatalf("discoverComputeDevices() returned error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"nvidia0", "nvidia1"}, got); diff != "" {
+		t.Error("unexpected devices (-want, +got) = ", diff)
+	}
+}
+
+func TestDiscoverComputeDevicesNoDevRoot(t *testing.T) {
+	got, err := discoverComputeDevices(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatalf("discoverComputeDevices() returned error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("discoverComputeDevices() = %v, want empty", got)
+	}
+}
+
+func TestBuildVirtualDevices(t *testing.T) {
+	got := buildVirtualDevices([]string{"nvidia0", "nvidia1"}, 2)
+	want := map[string][]string{
+		"nvidia0/vgpu0": {"/dev/nvidia0"},
+		"nvidia0/vgpu1": {"/dev/nvidia0"},
+		"nvidia1/vgpu0": {"/dev/nvidia1"},
+		"nvidia1/vgpu1": {"/dev/nvidia1"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("unexpected virtual devices (-want, +got) = ", diff)
+	}
+}
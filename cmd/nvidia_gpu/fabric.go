@@ -0,0 +1,43 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/labels"
+)
+
+// loadFabricInfo reads the NVLink fabric info for this node's physical
+// GPUs from path, a JSON object mapping physical device ID to its fabric
+// identifiers (e.g. {"nvidia0": {"clusterUUID": "...", "cliqueID": "0"}}),
+// for nodes where this isn't yet queried from NVML directly (see
+// nvmlDevices). An unset path returns an empty map, not an error.
+func loadFabricInfo(path string) (map[string]labels.GpuFabricInfo, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GPU fabric info config %s: %v", path, err)
+	}
+	var fabricInfo map[string]labels.GpuFabricInfo
+	if err := json.Unmarshal(data, &fabricInfo); err != nil {
+		return nil, fmt.Errorf("failed to parse GPU fabric info config %s: %v", path, err)
+	}
+	return fabricInfo, nil
+}
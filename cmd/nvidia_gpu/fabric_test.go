@@ -0,0 +1,60 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/labels"
+)
+
+func TestLoadFabricInfoNoPath(t *testing.T) {
+	fabricInfo, err := loadFabricInfo("")
+	if err != nil {
+		t.Fatalf("loadFabricInfo() returned error: %v", err)
+	}
+	if fabricInfo != nil {
+		t.Errorf("loadFabricInfo() = %v, want nil", fabricInfo)
+	}
+}
+
+func TestLoadFabricInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fabric.json")
+	contents := `{"nvidia0": {"clusterUUID": "cluster-a", "cliqueID": "0"}}`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write test fabric info config: %v", err)
+	}
+
+	fabricInfo, err := loadFabricInfo(path)
+	if err != nil {
+		t.Fatalf("loadFabricInfo() returned error: %v", err)
+	}
+	want := map[string]labels.GpuFabricInfo{
+		"nvidia0": {ClusterUUID: "cluster-a", CliqueID: "0"},
+	}
+	if diff := cmp.Diff(want, fabricInfo); diff != "" {
+		t.Error("unexpected fabric info (-want, +got) = ", diff)
+	}
+}
+
+func TestLoadFabricInfoMissingFile(t *testing.T) {
+	if _, err := loadFabricInfo(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loadFabricInfo() expected error for missing file, got nil")
+	}
+}
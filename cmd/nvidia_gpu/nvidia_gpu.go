@@ -0,0 +1,268 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Binary nvidia_gpu is the kubelet device plugin that advertises NVIDIA
+// GPUs on a GKE node and allocates them to containers.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/cdi"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/labels"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/mig"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/mps"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/sharing"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/time_sharing"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/vfio"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/vgpu/quota"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	gpuSharingStrategy = flag.String("gpu-sharing-strategy", "",
+		"comma separated list of GPU sharing strategies to enable, e.g. 'time-sharing', 'mig,time-sharing'")
+	deviceListStrategyFlag = flag.String("device-list-strategy", string(cdi.DeviceListStrategyEnvvar),
+		"mechanism used to pass allocated devices to a container: envvar, cdi-annotations or cdi-cri")
+	devicePluginModeFlag = flag.String("device-plugin-mode", string(vfio.ModeCompute),
+		"devices this plugin advertises and how it allocates them: compute, vfio or time-sharing")
+	vgpusPerDevice = flag.Int("vgpus-per-physical-gpu", 1,
+		"number of time-shared virtual devices to advertise per physical GPU")
+	devRoot = flag.String("dev-root", "/dev",
+		"root directory to scan for physical GPU device nodes")
+	vgpuQuotaConfigPath = flag.String("vgpu-quota-config", "",
+		"path to a JSON file mapping virtual device ID to its fractional vGPU quota; unset means no fractional quotas are enforced")
+	gpuMemoryBytes = flag.Int64("gpu-memory-bytes", 0,
+		"HBM capacity of each physical GPU, used to validate fractional vGPU memory quotas requested against it")
+	gpuFabricInfoConfigPath = flag.String("gpu-fabric-info-config", "",
+		"path to a JSON file mapping physical device ID to its NVLink fabric info, for nodes where this isn't yet queried from NVML directly")
+)
+
+func parseDeviceListStrategy() (cdi.DeviceListStrategy, error) {
+	strategy := cdi.DeviceListStrategy(*deviceListStrategyFlag)
+	switch strategy {
+	case cdi.DeviceListStrategyEnvvar, cdi.DeviceListStrategyCDIAnnotations, cdi.DeviceListStrategyCDICRI:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("invalid --device-list-strategy %q, must be one of envvar, cdi-annotations, cdi-cri", *deviceListStrategyFlag)
+	}
+}
+
+func parseDevicePluginMode() (vfio.Mode, error) {
+	mode := vfio.Mode(*devicePluginModeFlag)
+	switch mode {
+	case vfio.ModeCompute, vfio.ModeVFIO, vfio.ModeTimeSharing:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid --device-plugin-mode %q, must be one of compute, vfio, time-sharing", *devicePluginModeFlag)
+	}
+}
+
+// buildSharingStrategies constructs the sharing.SharingStrategy
+// implementations named in gpuSharingStrategy (e.g. "mig,mps" or
+// "mig,time-sharing"), so the device plugin can validate and allocate
+// through each enabled strategy without hard-coding any single one.
+func buildSharingStrategies(gpuSharingStrategy string, deviceListStrategy cdi.DeviceListStrategy, migDeviceManager *mig.DeviceManager, cdiSpec *cdi.Spec, fabricInfo map[string]labels.GpuFabricInfo, quotas map[string]quota.Quota, totalMemoryBytes map[string]int64) []sharing.SharingStrategy {
+	var strategies []sharing.SharingStrategy
+	if time_sharing.HasTimeSharingStrategy(gpuSharingStrategy) {
+		strategies = append(strategies, &time_sharing.Strategy{
+			MigDeviceManager:   migDeviceManager,
+			DeviceListStrategy: deviceListStrategy,
+			CDISpec:            cdiSpec,
+			FabricInfo:         fabricInfo,
+			Quotas:             quotas,
+			TotalMemoryBytes:   totalMemoryBytes,
+		})
+	}
+	if mps.HasMPSStrategy(gpuSharingStrategy) {
+		strategies = append(strategies, &mps.Strategy{Daemons: mps.NewDaemonManager()})
+	}
+	return strategies
+}
+
+func main() {
+	flag.Parse()
+
+	deviceListStrategy, err := parseDeviceListStrategy()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	mode, err := parseDevicePluginMode()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := vfio.ValidateMode(mode, *gpuSharingStrategy); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if mode == vfio.ModeVFIO {
+		if err := runVFIOPlugin(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := runComputePlugin(deviceListStrategy, mode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// effectiveSharingStrategy returns gpuSharingStrategy with "time-sharing"
+// folded in when mode is vfio.ModeTimeSharing, so that mode, not just
+// --gpu-sharing-strategy, determines whether time-sharing is enabled.
+func effectiveSharingStrategy(gpuSharingStrategy string, mode vfio.Mode) string {
+	if mode != vfio.ModeTimeSharing || time_sharing.HasTimeSharingStrategy(gpuSharingStrategy) {
+		return gpuSharingStrategy
+	}
+	if gpuSharingStrategy == "" {
+		return "time-sharing"
+	}
+	return gpuSharingStrategy + ",time-sharing"
+}
+
+// runComputePlugin discovers the physical GPUs on this node, builds the CDI
+// spec and sharing strategies enabled by gpuSharingStrategy (or implied by
+// mode == vfio.ModeTimeSharing), and serves them to the kubelet until the
+// process is stopped.
+func runComputePlugin(deviceListStrategy cdi.DeviceListStrategy, mode vfio.Mode) error {
+	physicalDevices, err := discoverComputeDevices(*devRoot)
+	if err != nil {
+		return err
+	}
+
+	cdiSpec := cdi.NewSpec(filepath.Join(cdi.DefaultSpecDir, cdi.SpecFileName))
+	virtualDevices := buildVirtualDevices(physicalDevices, *vgpusPerDevice)
+	if err := cdiSpec.UpdateDevices(virtualDevices); err != nil {
+		return fmt.Errorf("failed to write CDI spec: %v", err)
+	}
+
+	fabricInfo, err := publishFabricLabels()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	quotas, err := loadVGPUQuotas(*vgpuQuotaConfigPath)
+	if err != nil {
+		return err
+	}
+	totalMemoryBytes := totalMemoryBytesPerDevice(physicalDevices, *gpuMemoryBytes)
+
+	strategies := buildSharingStrategies(effectiveSharingStrategy(*gpuSharingStrategy, mode), deviceListStrategy, nil /* migDeviceManager */, cdiSpec, fabricInfo, quotas, totalMemoryBytes)
+
+	devices := physicalDevices
+	if len(strategies) > 0 {
+		devices = make([]string, 0, len(virtualDevices))
+		for virtualDeviceID := range virtualDevices {
+			devices = append(devices, virtualDeviceID)
+		}
+	}
+
+	plugin := &Plugin{
+		deviceListStrategy: deviceListStrategy,
+		strategies:         strategies,
+		cdiSpec:            cdiSpec,
+		deviceCount:        len(physicalDevices),
+		devices:            devices,
+	}
+	return plugin.Serve()
+}
+
+// runVFIOPlugin discovers GPUs bound to vfio-pci and serves them to the
+// kubelet for passthrough into a VM.
+func runVFIOPlugin() error {
+	manager := vfio.NewDeviceManager()
+	discovered, err := manager.Discover()
+	if err != nil {
+		return err
+	}
+
+	devices := make(map[string]vfio.Device, len(discovered))
+	deviceIDs := make([]string, 0, len(discovered))
+	for _, device := range discovered {
+		devices[device.ID] = device
+		deviceIDs = append(deviceIDs, device.ID)
+	}
+
+	plugin := &Plugin{
+		deviceCount: len(discovered),
+		devices:     deviceIDs,
+		vfioDevices: devices,
+	}
+	return plugin.Serve()
+}
+
+// publishFabricLabels collects the NVLink fabric info for this node's
+// physical GPUs and patches the corresponding cluster/clique labels onto
+// the Kubernetes Node object, returning the collected fabric info so
+// callers can also use it to validate NVLink locality. It is a no-op when
+// NODE_NAME is unset (e.g. running outside a pod) or no fabric info is
+// available for this node's GPUs.
+func publishFabricLabels() (map[string]labels.GpuFabricInfo, error) {
+	nodeName := os.Getenv("NODE_NAME")
+	if nodeName == "" {
+		return nil, nil
+	}
+
+	fabricInfo, err := loadFabricInfo(*gpuFabricInfoConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(fabricInfo) == 0 {
+		fabricInfo, err = labels.CollectFabricInfo(nvmlDevices())
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect GPU fabric info: %v", err)
+		}
+	}
+	nodeLabels, err := labels.NodeLabels(fabricInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive node labels from GPU fabric info: %v", err)
+	}
+	if len(nodeLabels) == 0 {
+		return nil, nil
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load in-cluster config: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Kubernetes clientset: %v", err)
+	}
+
+	labeler := &labels.NodeLabeler{Clientset: clientset, NodeName: nodeName}
+	if err := labeler.Apply(context.Background(), nodeLabels); err != nil {
+		return nil, err
+	}
+	return fabricInfo, nil
+}
+
+// nvmlDevices returns the NVML devices to query for fabric info, used as a
+// fallback when --gpu-fabric-info-config is unset. No real NVML binding is
+// plumbed in yet, so this always returns an empty map; nodes that need
+// clique validation today must supply --gpu-fabric-info-config instead.
+func nvmlDevices() map[string]labels.NVMLDevice {
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/vfio"
+)
+
+func TestEffectiveSharingStrategy(t *testing.T) {
+	cases := []struct {
+		name               string
+		gpuSharingStrategy string
+		mode               vfio.Mode
+		want               string
+	}{{
+		name:               "compute mode leaves gpuSharingStrategy untouched",
+		gpuSharingStrategy: "mig",
+		mode:               vfio.ModeCompute,
+		want:               "mig",
+	}, {
+		name:               "time-sharing mode folds in time-sharing when unset",
+		gpuSharingStrategy: "",
+		mode:               vfio.ModeTimeSharing,
+		want:               "time-sharing",
+	}, {
+		name:               "time-sharing mode appends time-sharing to other strategies",
+		gpuSharingStrategy: "mig",
+		mode:               vfio.ModeTimeSharing,
+		want:               "mig,time-sharing",
+	}, {
+		name:               "time-sharing mode is a no-op when already enabled",
+		gpuSharingStrategy: "time-sharing",
+		mode:               vfio.ModeTimeSharing,
+		want:               "time-sharing",
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := effectiveSharingStrategy(tc.gpuSharingStrategy, tc.mode)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error("unexpected result (-want, +got) = ", diff)
+			}
+		})
+	}
+}
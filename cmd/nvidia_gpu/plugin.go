@@ -0,0 +1,207 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/cdi"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/sharing"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/vfio"
+	"google.golang.org/grpc"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// resourceName is the extended resource this plugin advertises.
+const resourceName = "nvidia.com/gpu"
+
+// socketName is the device plugin's own gRPC socket, relative to
+// pluginapi.DevicePluginPath.
+const socketName = "nvidia-gpu.sock"
+
+// Plugin implements pluginapi.DevicePluginServer, serving the virtual
+// devices built from the physical GPUs discovered on this node and
+// dispatching Allocate requests to the enabled sharing strategies.
+type Plugin struct {
+	deviceListStrategy cdi.DeviceListStrategy
+	strategies         []sharing.SharingStrategy
+	cdiSpec            *cdi.Spec
+	deviceCount        int
+	devices            []string
+	// vfioDevices is set in --device-plugin-mode=vfio, keyed by device ID.
+	// When set, Allocate hands out the matching IOMMU group instead of
+	// dispatching through strategies.
+	vfioDevices map[string]vfio.Device
+}
+
+var _ pluginapi.DevicePluginServer = (*Plugin)(nil)
+
+// GetDevicePluginOptions implements pluginapi.DevicePluginServer.
+func (p *Plugin) GetDevicePluginOptions(ctx context.Context, e *pluginapi.Empty) (*pluginapi.DevicePluginOptions, error) {
+	return &pluginapi.DevicePluginOptions{}, nil
+}
+
+// ListAndWatch implements pluginapi.DevicePluginServer, advertising the
+// devices built at startup. The list is sent once and not re-sent; the
+// call then blocks until the stream ends.
+func (p *Plugin) ListAndWatch(e *pluginapi.Empty, stream pluginapi.DevicePlugin_ListAndWatchServer) error {
+	if err := stream.Send(&pluginapi.ListAndWatchResponse{Devices: p.pluginDevices()}); err != nil {
+		return fmt.Errorf("failed to send initial device list: %v", err)
+	}
+	<-stream.Context().Done()
+	return stream.Context().Err()
+}
+
+func (p *Plugin) pluginDevices() []*pluginapi.Device {
+	devices := make([]*pluginapi.Device, 0, len(p.devices))
+	for _, id := range p.devices {
+		devices = append(devices, &pluginapi.Device{ID: id, Health: pluginapi.Healthy})
+	}
+	return devices
+}
+
+// GetPreferredAllocation implements pluginapi.DevicePluginServer. This
+// plugin has no allocation preference among same-strategy virtual devices.
+func (p *Plugin) GetPreferredAllocation(ctx context.Context, req *pluginapi.PreferredAllocationRequest) (*pluginapi.PreferredAllocationResponse, error) {
+	return &pluginapi.PreferredAllocationResponse{}, nil
+}
+
+// PreStartContainer implements pluginapi.DevicePluginServer. Nothing needs
+// to run between container creation and start for this plugin.
+func (p *Plugin) PreStartContainer(ctx context.Context, req *pluginapi.PreStartContainerRequest) (*pluginapi.PreStartContainerResponse, error) {
+	return &pluginapi.PreStartContainerResponse{}, nil
+}
+
+// Allocate implements pluginapi.DevicePluginServer, validating and then
+// allocating each container's requested device IDs against every enabled
+// sharing strategy in turn, merging their responses.
+func (p *Plugin) Allocate(ctx context.Context, req *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	resp := &pluginapi.AllocateResponse{}
+	for _, containerReq := range req.ContainerRequests {
+		containerResp, err := p.allocateContainer(containerReq.DevicesIDs)
+		if err != nil {
+			return nil, err
+		}
+		resp.ContainerResponses = append(resp.ContainerResponses, containerResp)
+	}
+	return resp, nil
+}
+
+func (p *Plugin) allocateContainer(requestDeviceIDs []string) (*pluginapi.ContainerAllocateResponse, error) {
+	if p.vfioDevices != nil {
+		return p.allocateVFIO(requestDeviceIDs)
+	}
+
+	for _, strategy := range p.strategies {
+		if err := strategy.Validate(requestDeviceIDs, p.deviceCount); err != nil {
+			return nil, err
+		}
+	}
+
+	response := &pluginapi.ContainerAllocateResponse{}
+	for _, strategy := range p.strategies {
+		strategyResponse, err := strategy.Allocate(requestDeviceIDs)
+		if err != nil {
+			return nil, err
+		}
+		sharing.MergeAllocateResponse(response, strategyResponse)
+	}
+	if len(p.strategies) == 0 {
+		for _, deviceID := range requestDeviceIDs {
+			response.Devices = append(response.Devices, &pluginapi.DeviceSpec{
+				ContainerPath: fmt.Sprintf("/dev/%s", deviceID),
+				HostPath:      fmt.Sprintf("/dev/%s", deviceID),
+				Permissions:   "rw",
+			})
+		}
+	}
+	return response, nil
+}
+
+// allocateVFIO builds the ContainerAllocateResponse that passes the IOMMU
+// groups backing requestDeviceIDs through to the container.
+func (p *Plugin) allocateVFIO(requestDeviceIDs []string) (*pluginapi.ContainerAllocateResponse, error) {
+	response := &pluginapi.ContainerAllocateResponse{}
+	for _, deviceID := range requestDeviceIDs {
+		device, ok := p.vfioDevices[deviceID]
+		if !ok {
+			return nil, fmt.Errorf("unknown vfio device %s", deviceID)
+		}
+		sharing.MergeAllocateResponse(response, vfio.Allocate(device))
+	}
+	return response, nil
+}
+
+// Serve starts the plugin's gRPC server on its kubelet device-plugin
+// socket and registers it with the kubelet. It blocks until the server
+// stops.
+func (p *Plugin) Serve() error {
+	socketPath := filepath.Join(pluginapi.DevicePluginPath, socketName)
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale device plugin socket %s: %v", socketPath, err)
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on device plugin socket %s: %v", socketPath, err)
+	}
+
+	server := grpc.NewServer()
+	pluginapi.RegisterDevicePluginServer(server, p)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Serve(listener) }()
+
+	// Give the server a moment to start accepting connections before
+	// asking the kubelet to dial it back.
+	time.Sleep(time.Second)
+	if err := registerWithKubelet(socketName, resourceName); err != nil {
+		server.Stop()
+		return err
+	}
+	return <-errCh
+}
+
+// registerWithKubelet tells the kubelet to start talking to the device
+// plugin listening on pluginapi.DevicePluginPath/socketName, advertising
+// resourceName.
+func registerWithKubelet(socketName, resourceName string) error {
+	conn, err := grpc.Dial(pluginapi.KubeletSocket, grpc.WithInsecure(), grpc.WithBlock(),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}),
+		grpc.WithTimeout(10*time.Second),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to dial kubelet at %s: %v", pluginapi.KubeletSocket, err)
+	}
+	defer conn.Close()
+
+	client := pluginapi.NewRegistrationClient(conn)
+	_, err = client.Register(context.Background(), &pluginapi.RegisterRequest{
+		Version:      pluginapi.Version,
+		Endpoint:     socketName,
+		ResourceName: resourceName,
+		Options:      &pluginapi.DevicePluginOptions{},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register device plugin with kubelet: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/vfio"
+)
+
+func TestAllocateContainerVFIO(t *testing.T) {
+	plugin := &Plugin{
+		vfioDevices: map[string]vfio.Device{
+			"vfio-0000:00:04.0": {ID: "vfio-0000:00:04.0", PCIAddress: "0000:00:04.0", IOMMUGroup: "10"},
+		},
+	}
+
+	resp, err := plugin.allocateContainer([]string{"vfio-0000:00:04.0"})
+	if err != nil {
+		t.Fatalf("allocateContainer() returned error: %v", err)
+	}
+	if len(resp.Devices) != 2 {
+		t.Errorf("allocateContainer() = %d devices, want 2 (IOMMU group + /dev/vfio/vfio)", len(resp.Devices))
+	}
+
+	if _, err := plugin.allocateContainer([]string{"vfio-0000:00:05.0"}); err == nil {
+		t.Error("allocateContainer() expected error for unknown vfio device, got nil")
+	}
+}
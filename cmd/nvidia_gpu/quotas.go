@@ -0,0 +1,54 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/vgpu/quota"
+)
+
+// loadVGPUQuotas reads the fractional vGPU quota configuration from path,
+// a JSON object mapping virtual device ID to its quota (e.g.
+// {"nvidia0/vgpu0": {"memoryBytes": 4294967296, "smPercent": 25}}).
+// An unset path means no virtual device on this node has a fractional
+// quota.
+func loadVGPUQuotas(path string) (map[string]quota.Quota, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vGPU quota config %s: %v", path, err)
+	}
+	var quotas map[string]quota.Quota
+	if err := json.Unmarshal(data, &quotas); err != nil {
+		return nil, fmt.Errorf("failed to parse vGPU quota config %s: %v", path, err)
+	}
+	return quotas, nil
+}
+
+// totalMemoryBytesPerDevice reports every physical device in
+// physicalDeviceIDs as having totalMemoryBytes of HBM, for validating that
+// fractional requests sharing a GPU don't oversubscribe its memory.
+func totalMemoryBytesPerDevice(physicalDeviceIDs []string, totalMemoryBytes int64) map[string]int64 {
+	memory := make(map[string]int64, len(physicalDeviceIDs))
+	for _, physicalDeviceID := range physicalDeviceIDs {
+		memory[physicalDeviceID] = totalMemoryBytes
+	}
+	return memory
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/vgpu/quota"
+)
+
+func TestLoadVGPUQuotasNoPath(t *testing.T) {
+	quotas, err := loadVGPUQuotas("")
+	if err != nil {
+		t.Fatalf("loadVGPUQuotas() returned error: %v", err)
+	}
+	if quotas != nil {
+		t.Errorf("loadVGPUQuotas() = %v, want nil", quotas)
+	}
+}
+
+func TestLoadVGPUQuotas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.json")
+	contents := `{"nvidia0/vgpu0": {"memoryBytes": 4294967296, "smPercent": 25}}`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write test quota config: %v", err)
+	}
+
+	quotas, err := loadVGPUQuotas(path)
+	if err != nil {
+		t.Fatalf("loadVGPUQuotas() returned error: %v", err)
+	}
+	want := map[string]quota.Quota{
+		"nvidia0/vgpu0": {MemoryBytes: 4294967296, SMPercent: 25},
+	}
+	if diff := cmp.Diff(want, quotas); diff != "" {
+		t.Error("unexpected quotas (-want, +got) = ", diff)
+	}
+}
+
+func TestLoadVGPUQuotasMissingFile(t *testing.T) {
+	if _, err := loadVGPUQuotas(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loadVGPUQuotas() expected error for missing file, got nil")
+	}
+}
+
+func TestTotalMemoryBytesPerDevice(t *testing.T) {
+	got := totalMemoryBytesPerDevice([]string{"nvidia0", "nvidia1"}, 4294967296)
+	want := map[string]int64{"nvidia0": 4294967296, "nvidia1": 4294967296}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("unexpected result (-want, +got) = ", diff)
+	}
+}
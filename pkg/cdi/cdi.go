@@ -0,0 +1,184 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cdi generates and maintains a Container Device Interface (CDI)
+// spec file describing the GPU devices managed by this plugin, so that
+// container runtimes configured for CDI (e.g. containerd with
+// cdi-annotations or cdi-cri enabled) can perform the device node
+// injection themselves instead of the plugin mounting /dev nodes directly.
+package cdi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// DeviceListStrategy selects how a container's devices are communicated to
+// the container runtime.
+type DeviceListStrategy string
+
+const (
+	// DeviceListStrategyEnvvar mounts the /dev/nvidiaN device nodes backing
+	// each requested device directly; no environment variable is set.
+	DeviceListStrategyEnvvar DeviceListStrategy = "envvar"
+	// DeviceListStrategyCDIAnnotations asks the runtime to perform CDI
+	// injection via a pod annotation (cdi.k8s.io/<claim>).
+	DeviceListStrategyCDIAnnotations DeviceListStrategy = "cdi-annotations"
+	// DeviceListStrategyCDICRI asks the runtime to perform CDI injection via
+	// the CDIDevices field of the CRI ContainerAllocateResponse.
+	DeviceListStrategyCDICRI DeviceListStrategy = "cdi-cri"
+)
+
+// Kind is the CDI vendor/class this plugin registers devices under.
+const Kind = "nvidia.com/gpu"
+
+// DefaultSpecDir is where the time-sharing CDI spec is written, matching the
+// default CDI spec directories watched by containerd and CRI-O.
+const DefaultSpecDir = "/var/run/cdi"
+
+// SpecFileName is the name of the spec file generated for time-shared vGPUs.
+const SpecFileName = "nvidia-time-sharing.json"
+
+// cdiSpecVersion is the CDI spec schema version this package emits.
+const cdiSpecVersion = "0.6.0"
+
+// device is a single entry in the generated CDI spec.
+type device struct {
+	Name           string          `json:"name"`
+	ContainerEdits containerEdits `json:"containerEdits"`
+}
+
+type containerEdits struct {
+	DeviceNodes []deviceNode `json:"deviceNodes"`
+}
+
+type deviceNode struct {
+	Path string `json:"path"`
+}
+
+type cdiSpec struct {
+	Version string   `json:"cdiVersion"`
+	Kind    string   `json:"kind"`
+	Devices []device `json:"devices"`
+}
+
+// Spec tracks the set of virtual devices known to the plugin and keeps the
+// on-disk CDI spec file in sync with them.
+type Spec struct {
+	specPath string
+	devices  map[string][]string
+}
+
+// NewSpec creates a Spec that will be written to specPath.
+func NewSpec(specPath string) *Spec {
+	return &Spec{
+		specPath: specPath,
+		devices:  map[string][]string{},
+	}
+}
+
+// deviceName returns the unqualified CDI device name for a virtual device
+// ID, e.g. "nvidia0/vgpu0" -> "nvidia0-vgpu0".
+func deviceName(virtualDeviceID string) string {
+	return strings.ReplaceAll(virtualDeviceID, "/", "-")
+}
+
+// DeviceName returns the unqualified CDI device name for a virtual device
+// ID known to this Spec.
+func (s *Spec) DeviceName(virtualDeviceID string) (string, error) {
+	if _, ok := s.devices[virtualDeviceID]; !ok {
+		return "", fmt.Errorf("virtual device %s is not registered in the CDI spec", virtualDeviceID)
+	}
+	return deviceName(virtualDeviceID), nil
+}
+
+// QualifiedName returns the fully qualified CDI device name, e.g.
+// "nvidia.com/gpu=nvidia0-vgpu0".
+func QualifiedName(name string) string {
+	return fmt.Sprintf("%s=%s", Kind, name)
+}
+
+// UpdateDevices replaces the set of known virtual devices and the device
+// nodes backing each one, then regenerates the spec file on disk.
+func (s *Spec) UpdateDevices(devices map[string][]string) error {
+	s.devices = devices
+	return s.write()
+}
+
+func (s *Spec) write() error {
+	names := make([]string, 0, len(s.devices))
+	for virtualDeviceID := range s.devices {
+		names = append(names, virtualDeviceID)
+	}
+	sort.Strings(names)
+
+	spec := cdiSpec{
+		Version: cdiSpecVersion,
+		Kind:    Kind,
+	}
+	for _, virtualDeviceID := range names {
+		var nodes []deviceNode
+		for _, path := range s.devices[virtualDeviceID] {
+			nodes = append(nodes, deviceNode{Path: path})
+		}
+		spec.Devices = append(spec.Devices, device{
+			Name:           deviceName(virtualDeviceID),
+			ContainerEdits: containerEdits{DeviceNodes: nodes},
+		})
+	}
+
+	data, err := json.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal CDI spec: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.specPath), 0755); err != nil {
+		return fmt.Errorf("failed to create CDI spec directory: %v", err)
+	}
+	tmp := s.specPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write CDI spec: %v", err)
+	}
+	return os.Rename(tmp, s.specPath)
+}
+
+// AddDeviceToResponse records cdiName on response according to strategy, so
+// the container runtime injects the matching CDI device into the
+// container.
+func AddDeviceToResponse(response *pluginapi.ContainerAllocateResponse, strategy DeviceListStrategy, cdiName string) error {
+	qualified := QualifiedName(cdiName)
+	switch strategy {
+	case DeviceListStrategyCDIAnnotations:
+		if response.Annotations == nil {
+			response.Annotations = map[string]string{}
+		}
+		key := "cdi.k8s.io/nvidia-time-sharing"
+		if existing, ok := response.Annotations[key]; ok {
+			response.Annotations[key] = existing + "," + qualified
+		} else {
+			response.Annotations[key] = qualified
+		}
+		return nil
+	case DeviceListStrategyCDICRI:
+		response.CDIDevices = append(response.CDIDevices, &pluginapi.CDIDevice{Name: qualified})
+		return nil
+	default:
+		return fmt.Errorf("unsupported CDI device list strategy %q", strategy)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdi
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDeviceName(t *testing.T) {
+	spec := NewSpec(filepath.Join(t.TempDir(), SpecFileName))
+	if err := spec.UpdateDevices(map[string][]string{
+		"nvidia0/vgpu0": {"/dev/nvidia0"},
+	}); err != nil {
+		t.Fatalf("UpdateDevices() failed: %v", err)
+	}
+
+	got, err := spec.DeviceName("nvidia0/vgpu0")
+	if err != nil {
+		t.Fatalf("DeviceName() returned error: %v", err)
+	}
+	if diff := cmp.Diff("nvidia0-vgpu0", got); diff != "" {
+		t.Error("unexpected device name (-want, +got) = ", diff)
+	}
+
+	if _, err := spec.DeviceName("nvidia1/vgpu0"); err == nil {
+		t.Error("DeviceName() expected error for unregistered device, got nil")
+	}
+}
+
+func TestQualifiedName(t *testing.T) {
+	if diff := cmp.Diff("nvidia.com/gpu=nvidia0-vgpu0", QualifiedName("nvidia0-vgpu0")); diff != "" {
+		t.Error("unexpected qualified name (-want, +got) = ", diff)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package labels derives GKE node labels from the NVLink fabric topology of
+// the physical GPUs on a node, so the scheduler can reason about which
+// GPUs share an NVLink domain (e.g. a GB200 NVL72 clique).
+package labels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ClusterUUIDLabel identifies the NVLink fabric cluster a node's GPUs
+	// belong to.
+	ClusterUUIDLabel = "cloud.google.com/gke-gpu-cluster-uuid"
+	// CliqueIDLabel identifies the NVLink clique (fabric partition) a
+	// node's GPUs belong to within their cluster.
+	CliqueIDLabel = "cloud.google.com/gke-gpu-clique-id"
+)
+
+// GpuFabricInfo holds the GB200/NVL fabric identifiers for a single
+// physical GPU, as reported by NVML.
+type GpuFabricInfo struct {
+	ClusterUUID string `json:"clusterUUID"`
+	CliqueID    string `json:"cliqueID"`
+}
+
+// NVMLDevice is the subset of nvml.Device this package depends on, so tests
+// can inject a fake instead of talking to a real GPU.
+type NVMLDevice interface {
+	GetGpuFabricInfo() (GpuFabricInfo, error)
+}
+
+// CollectFabricInfo queries the NVLink fabric identifiers for each physical
+// device, keyed by physical device ID (e.g. "nvidia0").
+func CollectFabricInfo(devices map[string]NVMLDevice) (map[string]GpuFabricInfo, error) {
+	infos := make(map[string]GpuFabricInfo, len(devices))
+	for physicalDeviceID, device := range devices {
+		info, err := device.GetGpuFabricInfo()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get GPU fabric info for %s: %v", physicalDeviceID, err)
+		}
+		infos[physicalDeviceID] = info
+	}
+	return infos, nil
+}
+
+// NodeLabels derives the node labels to publish from the per-device fabric
+// info collected by CollectFabricInfo. All physical GPUs on a node are
+// expected to belong to the same NVLink cluster and clique; if they
+// disagree, NodeLabels returns an error rather than publishing a label that
+// is only true for some of the node's GPUs.
+func NodeLabels(infos map[string]GpuFabricInfo) (map[string]string, error) {
+	if len(infos) == 0 {
+		return nil, nil
+	}
+	var clusterUUID, cliqueID string
+	first := true
+	for physicalDeviceID, info := range infos {
+		if first {
+			clusterUUID, cliqueID = info.ClusterUUID, info.CliqueID
+			first = false
+			continue
+		}
+		if info.ClusterUUID != clusterUUID {
+			return nil, fmt.Errorf("device %s has cluster UUID %s, want %s", physicalDeviceID, info.ClusterUUID, clusterUUID)
+		}
+		if info.CliqueID != cliqueID {
+			return nil, fmt.Errorf("device %s has clique ID %s, want %s", physicalDeviceID, info.CliqueID, cliqueID)
+		}
+	}
+	return map[string]string{
+		ClusterUUIDLabel: clusterUUID,
+		CliqueIDLabel:    cliqueID,
+	}, nil
+}
+
+// CliqueIDForPhysicalDevice returns the CliqueId of the given physical
+// device ID, for the scheduler extender to consume when deciding whether
+// two vGPUs are NVLink-local.
+func CliqueIDForPhysicalDevice(physicalDeviceID string, infos map[string]GpuFabricInfo) (string, error) {
+	info, ok := infos[physicalDeviceID]
+	if !ok {
+		return "", fmt.Errorf("no fabric info known for device %s", physicalDeviceID)
+	}
+	return info.CliqueID, nil
+}
+
+// NodeLabeler publishes node labels computed by NodeLabels to the
+// Kubernetes API, so the scheduler extender can read them.
+type NodeLabeler struct {
+	Clientset kubernetes.Interface
+	NodeName  string
+}
+
+// Apply merge-patches labels onto this NodeLabeler's node. It is a no-op
+// when labels is empty, which NodeLabels returns when no fabric info was
+// collected.
+func (l *NodeLabeler) Apply(ctx context.Context, labels map[string]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": labels},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal node label patch: %v", err)
+	}
+	_, err = l.Clientset.CoreV1().Nodes().Patch(ctx, l.NodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to patch labels onto node %s: %v", l.NodeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNodeLabels(t *testing.T) {
+	cases := []struct {
+		name      string
+		infos     map[string]GpuFabricInfo
+		want      map[string]string
+		wantError bool
+	}{{
+		name:  "no devices",
+		infos: map[string]GpuFabricInfo{},
+		want:  nil,
+	}, {
+		name: "devices agree on cluster and clique",
+		infos: map[string]GpuFabricInfo{
+			"nvidia0": {ClusterUUID: "cluster-a", CliqueID: "0"},
+			"nvidia1": {ClusterUUID: "cluster-a", CliqueID: "0"},
+		},
+		want: map[string]string{
+			ClusterUUIDLabel: "cluster-a",
+			CliqueIDLabel:    "0",
+		},
+	}, {
+		name: "devices disagree on cluster",
+		infos: map[string]GpuFabricInfo{
+			"nvidia0": {ClusterUUID: "cluster-a"},
+			"nvidia1": {ClusterUUID: "cluster-b"},
+		},
+		wantError: true,
+	}, {
+		name: "devices disagree on clique",
+		infos: map[string]GpuFabricInfo{
+			"nvidia0": {ClusterUUID: "cluster-a", CliqueID: "0"},
+			"nvidia1": {ClusterUUID: "cluster-a", CliqueID: "1"},
+		},
+		wantError: true,
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NodeLabels(tc.infos)
+			if tc.wantError {
+				if err == nil {
+					t.Fatal("NodeLabels() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NodeLabels() returned error: %v", err)
+			}
+			if tc.want == nil {
+				if got != nil {
+					t.Errorf("NodeLabels() = %v, want nil", got)
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Error("unexpected node labels (-want, +got) = ", diff)
+			}
+		})
+	}
+}
+
+func TestCliqueIDForPhysicalDevice(t *testing.T) {
+	infos := map[string]GpuFabricInfo{
+		"nvidia0": {ClusterUUID: "cluster-a", CliqueID: "7"},
+	}
+	got, err := CliqueIDForPhysicalDevice("nvidia0", infos)
+	if err != nil {
+		t.Fatalf("CliqueIDForPhysicalDevice() returned error: %v", err)
+	}
+	if diff := cmp.Diff("7", got); diff != "" {
+		t.Error("unexpected clique ID (-want, +got) = ", diff)
+	}
+
+	if _, err := CliqueIDForPhysicalDevice("nvidia1", infos); err == nil {
+		t.Error("CliqueIDForPhysicalDevice() expected error for unknown device, got nil")
+	}
+}
+
+func TestNodeLabelerApply(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+	})
+	labeler := &NodeLabeler{Clientset: clientset, NodeName: "node-a"}
+
+	if err := labeler.Apply(context.Background(), map[string]string{
+		ClusterUUIDLabel: "cluster-a",
+		CliqueIDLabel:    "0",
+	}); err != nil {
+		t.Fatalf("Apply() returned error: %v", err)
+	}
+
+	node, err := clientset.CoreV1().Nodes().Get(context.Background(), "node-a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get patched node: %v", err)
+	}
+	want := map[string]string{
+		ClusterUUIDLabel: "cluster-a",
+		CliqueIDLabel:    "0",
+	}
+	if diff := cmp.Diff(want, node.Labels); diff != "" {
+		t.Error("unexpected node labels (-want, +got) = ", diff)
+	}
+}
+
+func TestNodeLabelerApplyNoLabels(t *testing.T) {
+	clientset := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+	})
+	labeler := &NodeLabeler{Clientset: clientset, NodeName: "node-a"}
+
+	if err := labeler.Apply(context.Background(), nil); err != nil {
+		t.Fatalf("Apply() returned error: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mig manages NVIDIA Multi-Instance GPU (MIG) devices, tracking the
+// GPU instances carved out of each physical GPU and the device nodes that
+// back them.
+package mig
+
+import "fmt"
+
+// Device represents a single MIG GPU instance, e.g. "nvidia0/gi0".
+type Device struct {
+	// DeviceNodes are the host paths (e.g. /dev/nvidia-caps/nvidia-cap10)
+	// that must be mounted into a container to access this GPU instance.
+	DeviceNodes []string
+}
+
+// DeviceManager keeps track of the MIG devices available on this node,
+// keyed by their physical device ID (e.g. "nvidia0/gi0").
+type DeviceManager struct {
+	devices map[string]Device
+}
+
+// NewDeviceManager creates a DeviceManager for the given set of MIG devices.
+func NewDeviceManager(devices map[string]Device) *DeviceManager {
+	return &DeviceManager{devices: devices}
+}
+
+// DeviceNodes returns the device nodes backing the MIG device with the given
+// physical ID (e.g. "nvidia0/gi0").
+func (m *DeviceManager) DeviceNodes(physicalDeviceID string) ([]string, error) {
+	device, ok := m.devices[physicalDeviceID]
+	if !ok {
+		return nil, fmt.Errorf("unknown MIG device %s", physicalDeviceID)
+	}
+	return device.DeviceNodes, nil
+}
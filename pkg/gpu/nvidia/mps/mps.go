@@ -0,0 +1,203 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mps implements the MPS (Multi-Process Service) GPU sharing
+// strategy, where containers sharing a physical GPU submit work through a
+// per-GPU nvidia-cuda-mps-control daemon instead of time-slicing direct
+// access to the device.
+package mps
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/sharing"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const mpsStrategy = "mps"
+
+// baseDir holds the per-physical-GPU pipe and log directories the MPS
+// control daemons use, mirroring the directories nvidia-cuda-mps-control
+// expects to find in CUDA_MPS_PIPE_DIRECTORY/CUDA_MPS_LOG_DIRECTORY.
+const baseDir = "/tmp/nvidia-mps"
+
+// HasMPSStrategy returns whether the comma-separated gpuSharingStrategy
+// list (e.g. "mig,mps") includes MPS.
+func HasMPSStrategy(gpuSharingStrategy string) bool {
+	for _, strategy := range strings.Split(gpuSharingStrategy, ",") {
+		if strategy == mpsStrategy {
+			return true
+		}
+	}
+	return false
+}
+
+// MPSRequestValidation validates that a pod's set of requested
+// nvidia.com/gpu device IDs is compatible with MPS on this node.
+// requestDeviceIDs contains the device IDs allocated to the container;
+// deviceCount is the number of physical GPUs on the node.
+func MPSRequestValidation(requestDeviceIDs []string, deviceCount int) error {
+	var virtualDeviceIDs []string
+	for _, id := range requestDeviceIDs {
+		if strings.Contains(id, "/") {
+			virtualDeviceIDs = append(virtualDeviceIDs, id)
+		}
+	}
+	if len(virtualDeviceIDs) <= 1 {
+		return nil
+	}
+	if deviceCount > 1 {
+		return fmt.Errorf("invalid request for MPS solution, at most 1 nvidia.com/gpu can be requested when there are more than 1 physical GPUs in a node")
+	}
+	return nil
+}
+
+// VirtualToPhysicalDeviceID strips the trailing vgpuN component off a
+// virtual device ID, returning the physical device ID it is backed by, e.g.
+// "nvidia0/vgpu0" -> "nvidia0".
+func VirtualToPhysicalDeviceID(virtualDeviceID string) (string, error) {
+	parts := strings.Split(virtualDeviceID, "/")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("virtual device ID (%s) is not valid", virtualDeviceID)
+	}
+	return strings.Join(parts[:len(parts)-1], "/"), nil
+}
+
+// pipeDir returns the CUDA_MPS_PIPE_DIRECTORY for a physical device.
+func pipeDir(physicalDeviceID string) string {
+	return filepath.Join(baseDir, physicalDeviceID, "pipe")
+}
+
+// logDir returns the CUDA_MPS_LOG_DIRECTORY for a physical device.
+func logDir(physicalDeviceID string) string {
+	return filepath.Join(baseDir, physicalDeviceID, "log")
+}
+
+// DaemonManager launches and monitors one nvidia-cuda-mps-control daemon
+// per physical GPU that has been requested under the MPS strategy.
+type DaemonManager struct {
+	mu      sync.Mutex
+	daemons map[string]*exec.Cmd
+}
+
+// NewDaemonManager creates an empty DaemonManager.
+func NewDaemonManager() *DaemonManager {
+	return &DaemonManager{daemons: map[string]*exec.Cmd{}}
+}
+
+// EnsureDaemon starts the MPS control daemon for physicalDeviceID if it is
+// not already running, creating its pipe and log directories first.
+func (m *DaemonManager) EnsureDaemon(physicalDeviceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.daemons[physicalDeviceID]; ok {
+		return nil
+	}
+
+	pipe, log := pipeDir(physicalDeviceID), logDir(physicalDeviceID)
+	if err := os.MkdirAll(pipe, 0755); err != nil {
+		return fmt.Errorf("failed to create MPS pipe directory for %s: %v", physicalDeviceID, err)
+	}
+	if err := os.MkdirAll(log, 0755); err != nil {
+		return fmt.Errorf("failed to create MPS log directory for %s: %v", physicalDeviceID, err)
+	}
+
+	cmd := exec.Command("nvidia-cuda-mps-control", "-d")
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("CUDA_VISIBLE_DEVICES=%s", physicalDeviceID),
+		fmt.Sprintf("CUDA_MPS_PIPE_DIRECTORY=%s", pipe),
+		fmt.Sprintf("CUDA_MPS_LOG_DIRECTORY=%s", log),
+	)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start nvidia-cuda-mps-control for %s: %v", physicalDeviceID, err)
+	}
+	m.daemons[physicalDeviceID] = cmd
+
+	// Reap the daemon when it exits and clear it from the map, so a crash
+	// is reflected immediately instead of leaving a zombie that
+	// EnsureDaemon believes is still running.
+	go func() {
+		cmd.Wait()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.daemons[physicalDeviceID] == cmd {
+			delete(m.daemons, physicalDeviceID)
+		}
+	}()
+	return nil
+}
+
+// Allocate builds the ContainerAllocateResponse for a set of requested
+// virtual device IDs, ensuring the MPS control daemon for each underlying
+// physical GPU is running and mounting its pipe directory plus the env
+// vars a CUDA client needs to find it.
+func (m *DaemonManager) Allocate(requestDeviceIDs []string) (*pluginapi.ContainerAllocateResponse, error) {
+	response := &pluginapi.ContainerAllocateResponse{Envs: map[string]string{}}
+	seen := map[string]bool{}
+	for _, virtualDeviceID := range requestDeviceIDs {
+		physicalDeviceID, err := VirtualToPhysicalDeviceID(virtualDeviceID)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.EnsureDaemon(physicalDeviceID); err != nil {
+			return nil, err
+		}
+		if seen[physicalDeviceID] {
+			continue
+		}
+		seen[physicalDeviceID] = true
+
+		pipe := pipeDir(physicalDeviceID)
+		response.Envs["CUDA_MPS_PIPE_DIRECTORY"] = pipe
+		response.Envs["CUDA_MPS_LOG_DIRECTORY"] = logDir(physicalDeviceID)
+		response.Mounts = append(response.Mounts, &pluginapi.Mount{
+			ContainerPath: pipe,
+			HostPath:      pipe,
+			ReadOnly:      false,
+		})
+	}
+	return response, nil
+}
+
+// Strategy adapts DaemonManager and the MPS validation functions in this
+// package to the sharing.SharingStrategy interface, so the device plugin's
+// main loop can dispatch to it alongside other enabled strategies (e.g.
+// time-sharing).
+type Strategy struct {
+	Daemons *DaemonManager
+}
+
+var _ sharing.SharingStrategy = (*Strategy)(nil)
+
+// Validate implements sharing.SharingStrategy.
+func (s *Strategy) Validate(requestDeviceIDs []string, deviceCount int) error {
+	return MPSRequestValidation(requestDeviceIDs, deviceCount)
+}
+
+// Allocate implements sharing.SharingStrategy.
+func (s *Strategy) Allocate(requestDeviceIDs []string) (*pluginapi.ContainerAllocateResponse, error) {
+	return s.Daemons.Allocate(requestDeviceIDs)
+}
+
+// VirtualToPhysical implements sharing.SharingStrategy.
+func (s *Strategy) VirtualToPhysical(virtualDeviceID string) (string, error) {
+	return VirtualToPhysicalDeviceID(virtualDeviceID)
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sharing defines the common interface implemented by every GPU
+// sharing strategy (time-sharing, MPS, ...), so the device plugin's main
+// loop can dispatch to whichever strategies are enabled on a node without
+// hard-coding any single one of them.
+package sharing
+
+import (
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// SharingStrategy is implemented by each GPU sharing strategy a node can
+// enable, e.g. time-sharing or MPS. Strategies can be combined on the same
+// node (for example "mig,mps" or "mig,time-sharing"), so the main loop
+// holds a slice of SharingStrategy and calls each in turn.
+type SharingStrategy interface {
+	// Validate checks that requestDeviceIDs, the virtual device IDs
+	// requested by a container, are a valid request for this strategy
+	// given deviceCount physical GPUs on the node.
+	Validate(requestDeviceIDs []string, deviceCount int) error
+
+	// Allocate builds the ContainerAllocateResponse that grants a
+	// container access to requestDeviceIDs under this strategy.
+	Allocate(requestDeviceIDs []string) (*pluginapi.ContainerAllocateResponse, error)
+
+	// VirtualToPhysical resolves a virtual device ID to the physical
+	// device ID backing it, e.g. "nvidia0/vgpu0" -> "nvidia0".
+	VirtualToPhysical(virtualDeviceID string) (string, error)
+}
+
+// MergeAllocateResponse merges src into dst in place, so the main loop can
+// combine the ContainerAllocateResponse returned by each enabled
+// SharingStrategy into the single response sent back to the kubelet.
+func MergeAllocateResponse(dst, src *pluginapi.ContainerAllocateResponse) {
+	dst.Devices = append(dst.Devices, src.Devices...)
+	dst.Mounts = append(dst.Mounts, src.Mounts...)
+	dst.CDIDevices = append(dst.CDIDevices, src.CDIDevices...)
+	for k, v := range src.Envs {
+		if dst.Envs == nil {
+			dst.Envs = map[string]string{}
+		}
+		dst.Envs[k] = v
+	}
+	for k, v := range src.Annotations {
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[k] = v
+	}
+}
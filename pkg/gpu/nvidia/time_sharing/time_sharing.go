@@ -0,0 +1,300 @@
+// Copyright 2021 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package time_sharing implements the time-sharing GPU sharing strategy,
+// where multiple virtual devices (vgpuN) are backed by the same physical
+// GPU and the kubelet device plugin hands out a virtual device per
+// container request.
+package time_sharing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/cdi"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/labels"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/mig"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/sharing"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/vgpu/quota"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+const timeSharingStrategy = "time-sharing"
+
+// HasTimeSharingStrategy returns whether the comma-separated
+// gpuSharingStrategy list (e.g. "mig,time-sharing") includes time-sharing.
+func HasTimeSharingStrategy(gpuSharingStrategy string) bool {
+	for _, strategy := range strings.Split(gpuSharingStrategy, ",") {
+		if strategy == timeSharingStrategy {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeSharingRequestValidation validates that a pod's set of requested
+// nvidia.com/gpu device IDs is compatible with time-sharing on this node.
+// requestDeviceIDs contains the device IDs allocated to the container;
+// deviceCount is the number of physical GPUs on the node; migDeviceManager
+// is non-nil when the node is running in MIG mode.
+func TimeSharingRequestValidation(requestDeviceIDs []string, deviceCount int, migDeviceManager *mig.DeviceManager) error {
+	var virtualDeviceIDs []string
+	isMIG := false
+	for _, id := range requestDeviceIDs {
+		if strings.Contains(id, "/") {
+			virtualDeviceIDs = append(virtualDeviceIDs, id)
+		}
+		if strings.Contains(id, "/gi") {
+			isMIG = true
+		}
+	}
+	if len(virtualDeviceIDs) <= 1 {
+		return nil
+	}
+	if isMIG {
+		if migDeviceManager == nil {
+			return fmt.Errorf("invalid request for time-sharing solution, node suppose to be in MIG mode, but can't find MIG device manager")
+		}
+		return nil
+	}
+	if deviceCount > 1 {
+		return fmt.Errorf("invalid request for time-sharing solution, at most 1 nvidia.com/gpu can be requested when there are more than 1 physical GPUs in a node")
+	}
+	return nil
+}
+
+// VirtualToPhysicalDeviceID strips the trailing vgpuN component off a
+// virtual device ID, returning the physical device ID it is backed by, e.g.
+// "nvidia0/vgpu0" -> "nvidia0" and "nvidia0/gi0/vgpu0" -> "nvidia0/gi0".
+func VirtualToPhysicalDeviceID(virtualDeviceID string) (string, error) {
+	parts := strings.Split(virtualDeviceID, "/")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("virtual device ID (%s) is not valid", virtualDeviceID)
+	}
+	return strings.Join(parts[:len(parts)-1], "/"), nil
+}
+
+// TimeSharingCliqueValidation rejects a time-sharing request whose virtual
+// devices resolve to physical GPUs in different NVLink cliques, since
+// time-sharing across NVLink domains defeats the locality a caller expects
+// from co-located vGPUs. fabricInfo maps physical device ID to the fabric
+// info published by pkg/gpu/nvidia/labels; physical devices missing from
+// fabricInfo (e.g. no NVLink fabric present) are ignored.
+func TimeSharingCliqueValidation(requestDeviceIDs []string, fabricInfo map[string]labels.GpuFabricInfo) error {
+	var clique string
+	first := true
+	for _, virtualDeviceID := range requestDeviceIDs {
+		physicalDeviceID, err := VirtualToPhysicalDeviceID(virtualDeviceID)
+		if err != nil {
+			continue
+		}
+		info, ok := fabricInfo[physicalDeviceID]
+		if !ok {
+			continue
+		}
+		if first {
+			clique = info.CliqueID
+			first = false
+			continue
+		}
+		if info.CliqueID != clique {
+			return fmt.Errorf("invalid request for time-sharing solution, requested physical GPUs are in different NVLink cliques (%s, %s)", clique, info.CliqueID)
+		}
+	}
+	return nil
+}
+
+// TimeSharingQuotaValidation validates a physical GPU's fractional vGPU
+// quotas (nvidia.com/gpu-memory, nvidia.com/gpu-cores), requested in
+// addition to, or instead of, whole vGPU counts. It rejects a physical
+// GPU whose fractional requests would exceed its SM cores or HBM
+// capacity; see pkg/gpu/nvidia/vgpu/quota for the quota schema.
+func TimeSharingQuotaValidation(physicalDeviceID string, quotas map[string]quota.Quota, totalMemoryBytes int64) error {
+	return quota.ValidateQuotas(physicalDeviceID, quotas, totalMemoryBytes)
+}
+
+// AllocateQuota builds the ContainerAllocateResponse for a container
+// granted a fractional vGPU, bind-mounting the libvgpu.so preload library
+// that enforces q instead of (or alongside) the /dev/nvidiaN mount
+// returned by Allocate.
+func AllocateQuota(containerID string, q quota.Quota) (*pluginapi.ContainerAllocateResponse, error) {
+	return quota.Allocate(containerID, q)
+}
+
+// Allocate builds the ContainerAllocateResponse for a set of requested
+// virtual device IDs. When strategy is cdi-annotations or cdi-cri, the
+// response references CDI device names generated by pkg/cdi instead of
+// mounting /dev/nvidiaN directly, so the container runtime performs the
+// device injection.
+func Allocate(requestDeviceIDs []string, strategy cdi.DeviceListStrategy, migDeviceManager *mig.DeviceManager, spec *cdi.Spec) (*pluginapi.ContainerAllocateResponse, error) {
+	response := &pluginapi.ContainerAllocateResponse{}
+	for _, virtualDeviceID := range requestDeviceIDs {
+		physicalDeviceID, err := VirtualToPhysicalDeviceID(virtualDeviceID)
+		if err != nil {
+			return nil, err
+		}
+		deviceNodes, err := deviceNodesForPhysicalID(physicalDeviceID, migDeviceManager)
+		if err != nil {
+			return nil, err
+		}
+		switch strategy {
+		case cdi.DeviceListStrategyCDIAnnotations, cdi.DeviceListStrategyCDICRI:
+			cdiName, err := spec.DeviceName(virtualDeviceID)
+			if err != nil {
+				return nil, err
+			}
+			if err := cdi.AddDeviceToResponse(response, strategy, cdiName); err != nil {
+				return nil, err
+			}
+		default:
+			for _, node := range deviceNodes {
+				response.Devices = append(response.Devices, &pluginapi.DeviceSpec{
+					ContainerPath: node,
+					HostPath:      node,
+					Permissions:   "rw",
+				})
+			}
+		}
+	}
+	return response, nil
+}
+
+func deviceNodesForPhysicalID(physicalDeviceID string, migDeviceManager *mig.DeviceManager) ([]string, error) {
+	if strings.Contains(physicalDeviceID, "/gi") {
+		if migDeviceManager == nil {
+			return nil, fmt.Errorf("node suppose to be in MIG mode, but can't find MIG device manager")
+		}
+		return migDeviceManager.DeviceNodes(physicalDeviceID)
+	}
+	return []string{fmt.Sprintf("/dev/%s", physicalDeviceID)}, nil
+}
+
+// Strategy adapts the time-sharing functions in this package to the
+// sharing.SharingStrategy interface, so the device plugin's main loop can
+// dispatch to it alongside other enabled strategies (e.g. MPS).
+type Strategy struct {
+	MigDeviceManager   *mig.DeviceManager
+	DeviceListStrategy cdi.DeviceListStrategy
+	CDISpec            *cdi.Spec
+	// FabricInfo is the NVLink fabric info collected by pkg/gpu/nvidia/labels,
+	// keyed by physical device ID. It is nil on nodes without NVLink fabric,
+	// in which case clique locality is not validated.
+	FabricInfo map[string]labels.GpuFabricInfo
+	// Quotas holds the fractional vGPU quota configured for each virtual
+	// device ID that requests one, keyed by virtual device ID. Virtual
+	// devices absent from Quotas are allocated as whole vGPUs.
+	Quotas map[string]quota.Quota
+	// TotalMemoryBytes is the HBM capacity of each physical GPU, keyed by
+	// physical device ID, used to validate that the fractional requests
+	// sharing it don't oversubscribe its memory.
+	TotalMemoryBytes map[string]int64
+}
+
+var _ sharing.SharingStrategy = (*Strategy)(nil)
+
+// Validate implements sharing.SharingStrategy.
+func (s *Strategy) Validate(requestDeviceIDs []string, deviceCount int) error {
+	if err := TimeSharingRequestValidation(requestDeviceIDs, deviceCount, s.MigDeviceManager); err != nil {
+		return err
+	}
+	if s.FabricInfo != nil {
+		if err := TimeSharingCliqueValidation(requestDeviceIDs, s.FabricInfo); err != nil {
+			return err
+		}
+	}
+	return s.validateQuotas(requestDeviceIDs)
+}
+
+// validateQuotas checks that, for every physical GPU backing a requested
+// virtual device with a configured quota, the fractional requests sharing
+// that physical GPU still fit within its SM cores and HBM capacity. The
+// kubelet calls Validate once per container, so a physical GPU shared by
+// two single-vGPU pods is validated one call at a time; to still catch
+// their combined oversubscription, this validates against every virtual
+// device in s.Quotas backed by a requested physical GPU, not just the
+// virtual devices in this particular request.
+func (s *Strategy) validateQuotas(requestDeviceIDs []string) error {
+	if len(s.Quotas) == 0 {
+		return nil
+	}
+	requestedPhysical := map[string]bool{}
+	for _, virtualDeviceID := range requestDeviceIDs {
+		if _, ok := s.Quotas[virtualDeviceID]; !ok {
+			continue
+		}
+		physicalDeviceID, err := VirtualToPhysicalDeviceID(virtualDeviceID)
+		if err != nil {
+			return err
+		}
+		requestedPhysical[physicalDeviceID] = true
+	}
+	if len(requestedPhysical) == 0 {
+		return nil
+	}
+
+	quotasByPhysical := map[string]map[string]quota.Quota{}
+	for virtualDeviceID, q := range s.Quotas {
+		physicalDeviceID, err := VirtualToPhysicalDeviceID(virtualDeviceID)
+		if err != nil {
+			return err
+		}
+		if !requestedPhysical[physicalDeviceID] {
+			continue
+		}
+		if quotasByPhysical[physicalDeviceID] == nil {
+			quotasByPhysical[physicalDeviceID] = map[string]quota.Quota{}
+		}
+		quotasByPhysical[physicalDeviceID][virtualDeviceID] = q
+	}
+	for physicalDeviceID, quotas := range quotasByPhysical {
+		if err := TimeSharingQuotaValidation(physicalDeviceID, quotas, s.TotalMemoryBytes[physicalDeviceID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Allocate implements sharing.SharingStrategy.
+func (s *Strategy) Allocate(requestDeviceIDs []string) (*pluginapi.ContainerAllocateResponse, error) {
+	response, err := Allocate(requestDeviceIDs, s.DeviceListStrategy, s.MigDeviceManager, s.CDISpec)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Quotas) == 0 {
+		return response, nil
+	}
+
+	for _, virtualDeviceID := range requestDeviceIDs {
+		q, ok := s.Quotas[virtualDeviceID]
+		if !ok {
+			continue
+		}
+		// Each quota'd virtual device gets its own config file and FIFO,
+		// keyed by its own ID, so a container requesting more than one
+		// doesn't have one device's quota file clobber another's.
+		containerID := strings.ReplaceAll(virtualDeviceID, "/", "-")
+		quotaResponse, err := AllocateQuota(containerID, q)
+		if err != nil {
+			return nil, err
+		}
+		sharing.MergeAllocateResponse(response, quotaResponse)
+	}
+	return response, nil
+}
+
+// VirtualToPhysical implements sharing.SharingStrategy.
+func (s *Strategy) VirtualToPhysical(virtualDeviceID string) (string, error) {
+	return VirtualToPhysicalDeviceID(virtualDeviceID)
+}
@@ -20,7 +20,9 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/labels"
 	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/mig"
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/vgpu/quota"
 )
 
 func TestHasTimeSharingStrategy(t *testing.T) {
@@ -98,6 +100,111 @@ func TestTimeSharingRequestValidation(t *testing.T) {
 	}
 }
 
+func TestTimeSharingCliqueValidation(t *testing.T) {
+	cases := []struct {
+		name              string
+		requestDevicesIDs []string
+		fabricInfo        map[string]labels.GpuFabricInfo
+		wantError         error
+	}{{
+		name:              "no fabric info known",
+		requestDevicesIDs: []string{"nvidia0/vgpu0", "nvidia1/vgpu1"},
+		fabricInfo:        map[string]labels.GpuFabricInfo{},
+		wantError:         nil,
+	}, {
+		name:              "same clique",
+		requestDevicesIDs: []string{"nvidia0/vgpu0", "nvidia1/vgpu1"},
+		fabricInfo: map[string]labels.GpuFabricInfo{
+			"nvidia0": {CliqueID: "0"},
+			"nvidia1": {CliqueID: "0"},
+		},
+		wantError: nil,
+	}, {
+		name:              "different cliques",
+		requestDevicesIDs: []string{"nvidia0/vgpu0", "nvidia1/vgpu1"},
+		fabricInfo: map[string]labels.GpuFabricInfo{
+			"nvidia0": {CliqueID: "0"},
+			"nvidia1": {CliqueID: "1"},
+		},
+		wantError: errors.New("invalid request for time-sharing solution, requested physical GPUs are in different NVLink cliques (0, 1)"),
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := TimeSharingCliqueValidation(tc.requestDevicesIDs, tc.fabricInfo)
+			if err != nil && tc.wantError != nil {
+				if diff := cmp.Diff(tc.wantError.Error(), err.Error()); diff != "" {
+					t.Error("unexpected error (-want, +got) = ", diff)
+				}
+			} else if err != nil {
+				t.Error("unexpected error: ", err)
+			} else if tc.wantError != nil {
+				t.Error("unexpected want error:", err)
+			}
+		})
+	}
+}
+
+func TestStrategyValidateQuotas(t *testing.T) {
+	cases := []struct {
+		name              string
+		requestDevicesIDs []string
+		quotas            map[string]quota.Quota
+		totalMemoryBytes  map[string]int64
+		wantError         error
+	}{{
+		name:              "no quotas configured",
+		requestDevicesIDs: []string{"nvidia0/vgpu0"},
+	}, {
+		name:              "request has no quota",
+		requestDevicesIDs: []string{"nvidia0/vgpu0"},
+		quotas: map[string]quota.Quota{
+			"nvidia0/vgpu1": {SMPercent: 50},
+		},
+		totalMemoryBytes: map[string]int64{"nvidia0": 1000},
+	}, {
+		name:              "quota within capacity",
+		requestDevicesIDs: []string{"nvidia0/vgpu0"},
+		quotas: map[string]quota.Quota{
+			"nvidia0/vgpu0": {MemoryBytes: 500, SMPercent: 50},
+		},
+		totalMemoryBytes: map[string]int64{"nvidia0": 1000},
+	}, {
+		name:              "quota exceeds SM capacity",
+		requestDevicesIDs: []string{"nvidia0/vgpu0"},
+		quotas: map[string]quota.Quota{
+			"nvidia0/vgpu0": {SMPercent: 150},
+		},
+		totalMemoryBytes: map[string]int64{"nvidia0": 1000},
+		wantError:         errors.New("invalid vGPU quota request for nvidia0, requested SM percentages sum to 150%, want at most 100%"),
+	}, {
+		name:              "sibling pod's quota on same physical GPU pushes it over capacity",
+		requestDevicesIDs: []string{"nvidia0/vgpu0"},
+		quotas: map[string]quota.Quota{
+			"nvidia0/vgpu0": {SMPercent: 60},
+			"nvidia0/vgpu1": {SMPercent: 60},
+		},
+		totalMemoryBytes: map[string]int64{"nvidia0": 1000},
+		wantError:         errors.New("invalid vGPU quota request for nvidia0, requested SM percentages sum to 120%, want at most 100%"),
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Strategy{Quotas: tc.quotas, TotalMemoryBytes: tc.totalMemoryBytes}
+			err := s.validateQuotas(tc.requestDevicesIDs)
+			if err != nil && tc.wantError != nil {
+				if diff := cmp.Diff(tc.wantError.Error(), err.Error()); diff != "" {
+					t.Error("unexpected error (-want, +got) = ", diff)
+				}
+			} else if err != nil {
+				t.Error("unexpected error: ", err)
+			} else if tc.wantError != nil {
+				t.Error("unexpected want error:", err)
+			}
+		})
+	}
+}
+
 func TestVirtualToPhysicalDeviceID(t *testing.T) {
 	cases := []struct {
 		name            string
@@ -0,0 +1,155 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vfio implements the VFIO passthrough device-plugin mode, which
+// advertises GPUs bound to the vfio-pci driver as nvidia.com/gpu devices
+// and allocates them by handing out their IOMMU group instead of the
+// /dev/nvidiaN compute device nodes, so a container (typically a VM
+// launcher such as KubeVirt) can assign the GPU directly to a guest.
+package vfio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/container-engine-accelerators/pkg/gpu/nvidia/time_sharing"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// Mode selects which devices a device plugin exposes and how it allocates
+// them.
+type Mode string
+
+const (
+	// ModeCompute allocates /dev/nvidiaN compute device nodes, as the
+	// plugin has always done.
+	ModeCompute Mode = "compute"
+	// ModeVFIO allocates GPUs bound to vfio-pci for passthrough into a VM.
+	ModeVFIO Mode = "vfio"
+	// ModeTimeSharing is ModeCompute plus the time-sharing GPU sharing
+	// strategy.
+	ModeTimeSharing Mode = "time-sharing"
+)
+
+var pciAddressPattern = regexp.MustCompile(`^[0-9a-fA-F]{4}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}\.[0-9a-fA-F]$`)
+
+// Device is a GPU bound to the vfio-pci driver, ready for passthrough.
+type Device struct {
+	// ID is the nvidia.com/gpu device ID advertised to the kubelet, e.g.
+	// "vfio-0000:00:04.0".
+	ID string
+	// PCIAddress is the device's PCI address, e.g. "0000:00:04.0".
+	PCIAddress string
+	// IOMMUGroup is the IOMMU group number the device belongs to; every
+	// device in the group must be passed through together.
+	IOMMUGroup string
+}
+
+// DeviceManager discovers GPUs bound to the vfio-pci driver.
+type DeviceManager struct {
+	sysfsRoot string
+}
+
+// NewDeviceManager creates a DeviceManager that reads the host's sysfs.
+func NewDeviceManager() *DeviceManager {
+	return &DeviceManager{sysfsRoot: "/sys"}
+}
+
+// NewDeviceManagerWithRoot creates a DeviceManager that reads sysfsRoot
+// instead of "/sys", so tests can stub the driver and IOMMU group layout.
+func NewDeviceManagerWithRoot(sysfsRoot string) *DeviceManager {
+	return &DeviceManager{sysfsRoot: sysfsRoot}
+}
+
+func (m *DeviceManager) vfioPCIDriverDir() string {
+	return filepath.Join(m.sysfsRoot, "bus", "pci", "drivers", "vfio-pci")
+}
+
+func (m *DeviceManager) pciDeviceDir(pciAddress string) string {
+	return filepath.Join(m.sysfsRoot, "bus", "pci", "devices", pciAddress)
+}
+
+// Discover returns every GPU currently bound to the vfio-pci driver. It
+// returns an empty slice, not an error, when no device is bound to
+// vfio-pci (e.g. vfio-pci is not loaded).
+func (m *DeviceManager) Discover() ([]Device, error) {
+	entries, err := os.ReadDir(m.vfioPCIDriverDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list devices bound to vfio-pci: %v", err)
+	}
+
+	var devices []Device
+	for _, entry := range entries {
+		pciAddress := entry.Name()
+		if !pciAddressPattern.MatchString(pciAddress) {
+			// The driver directory also contains non-device entries such
+			// as "bind", "unbind" and "new_id".
+			continue
+		}
+		group, err := m.iommuGroup(pciAddress)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, Device{
+			ID:         fmt.Sprintf("vfio-%s", pciAddress),
+			PCIAddress: pciAddress,
+			IOMMUGroup: group,
+		})
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].PCIAddress < devices[j].PCIAddress })
+	return devices, nil
+}
+
+func (m *DeviceManager) iommuGroup(pciAddress string) (string, error) {
+	link := filepath.Join(m.pciDeviceDir(pciAddress), "iommu_group")
+	target, err := os.Readlink(link)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve IOMMU group for %s: %v", pciAddress, err)
+	}
+	return filepath.Base(target), nil
+}
+
+// Allocate builds the ContainerAllocateResponse that grants a container
+// the IOMMU group backing device, plus the shared /dev/vfio/vfio control
+// device every VFIO group needs.
+func Allocate(device Device) *pluginapi.ContainerAllocateResponse {
+	groupPath := fmt.Sprintf("/dev/vfio/%s", device.IOMMUGroup)
+	return &pluginapi.ContainerAllocateResponse{
+		Devices: []*pluginapi.DeviceSpec{
+			{ContainerPath: groupPath, HostPath: groupPath, Permissions: "rw"},
+			{ContainerPath: "/dev/vfio/vfio", HostPath: "/dev/vfio/vfio", Permissions: "rw"},
+		},
+	}
+}
+
+// ValidateMode returns an error if mode and gpuSharingStrategy are
+// mutually exclusive. VFIO passthrough hands whole GPUs to a VM, so it
+// cannot be combined with time-sharing, which relies on the plugin
+// managing concurrent access to a physical GPU itself.
+func ValidateMode(mode Mode, gpuSharingStrategy string) error {
+	if mode != ModeVFIO {
+		return nil
+	}
+	if time_sharing.HasTimeSharingStrategy(gpuSharingStrategy) {
+		return fmt.Errorf("invalid configuration, --device-plugin-mode=vfio cannot be combined with the time-sharing GPU sharing strategy")
+	}
+	return nil
+}
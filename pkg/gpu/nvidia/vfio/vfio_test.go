@@ -0,0 +1,130 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vfio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// setupFakeSysfs stubs /sys/bus/pci/drivers/vfio-pci and the IOMMU group
+// layout for the given PCI addresses, returning the sysfs root.
+func setupFakeSysfs(t *testing.T, pciAddressToGroup map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	driverDir := filepath.Join(root, "bus", "pci", "drivers", "vfio-pci")
+	if err := os.MkdirAll(driverDir, 0755); err != nil {
+		t.Fatalf("failed to create fake driver dir: %v", err)
+	}
+	// Entries that should be ignored because they aren't PCI addresses.
+	for _, name := range []string{"bind", "unbind", "new_id"} {
+		if err := os.WriteFile(filepath.Join(driverDir, name), nil, 0644); err != nil {
+			t.Fatalf("failed to create fake driver entry: %v", err)
+		}
+	}
+
+	for pciAddress, group := range pciAddressToGroup {
+		deviceDir := filepath.Join(root, "bus", "pci", "devices", pciAddress)
+		if err := os.MkdirAll(deviceDir, 0755); err != nil {
+			t.Fatalf("failed to create fake device dir: %v", err)
+		}
+		groupDir := filepath.Join(root, "kernel", "iommu_groups", group)
+		if err := os.MkdirAll(groupDir, 0755); err != nil {
+			t.Fatalf("failed to create fake IOMMU group dir: %v", err)
+		}
+		if err := os.Symlink(groupDir, filepath.Join(deviceDir, "iommu_group")); err != nil {
+			t.Fatalf("failed to symlink IOMMU group: %v", err)
+		}
+		if err := os.Symlink(deviceDir, filepath.Join(driverDir, pciAddress)); err != nil {
+			t.Fatalf("failed to symlink vfio-pci bound device: %v", err)
+		}
+	}
+	return root
+}
+
+func TestDiscover(t *testing.T) {
+	root := setupFakeSysfs(t, map[string]string{
+		"0000:00:04.0": "10",
+		"0000:00:05.0": "11",
+	})
+
+	manager := NewDeviceManagerWithRoot(root)
+	got, err := manager.Discover()
+	if err != nil {
+		t.Fatalf("Discover() returned error: %v", err)
+	}
+
+	want := []Device{
+		{ID: "vfio-0000:00:04.0", PCIAddress: "0000:00:04.0", IOMMUGroup: "10"},
+		{ID: "vfio-0000:00:05.0", PCIAddress: "0000:00:05.0", IOMMUGroup: "11"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("unexpected devices (-want, +got) = ", diff)
+	}
+}
+
+func TestDiscoverNoVFIODriver(t *testing.T) {
+	manager := NewDeviceManagerWithRoot(t.TempDir())
+	got, err := manager.Discover()
+	if err != nil {
+		t.Fatalf("Discover() returned error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Discover() = %v, want empty", got)
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+	cases := []struct {
+		name               string
+		mode               Mode
+		gpuSharingStrategy string
+		wantError          error
+	}{{
+		name:               "compute mode never conflicts",
+		mode:               ModeCompute,
+		gpuSharingStrategy: "mig,time-sharing",
+		wantError:          nil,
+	}, {
+		name:               "vfio mode without time-sharing",
+		mode:               ModeVFIO,
+		gpuSharingStrategy: "mig,mps",
+		wantError:          nil,
+	}, {
+		name:               "vfio mode with time-sharing",
+		mode:               ModeVFIO,
+		gpuSharingStrategy: "mig,time-sharing",
+		wantError:          errors.New("invalid configuration, --device-plugin-mode=vfio cannot be combined with the time-sharing GPU sharing strategy"),
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMode(tc.mode, tc.gpuSharingStrategy)
+			if err != nil && tc.wantError != nil {
+				if diff := cmp.Diff(tc.wantError.Error(), err.Error()); diff != "" {
+					t.Error("unexpected error (-want, +got) = ", diff)
+				}
+			} else if err != nil {
+				t.Error("unexpected error: ", err)
+			} else if tc.wantError != nil {
+				t.Error("unexpected want error:", err)
+			}
+		})
+	}
+}
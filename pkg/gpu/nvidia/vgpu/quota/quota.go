@@ -0,0 +1,128 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package quota owns the config-file schema and allocation glue for
+// fractional vGPU requests (nvidia.com/gpu-memory, nvidia.com/gpu-cores).
+// A container granted a fractional vGPU gets a config file describing its
+// memory cap and SM share plus a bind-mounted libvgpu.so preload library
+// that intercepts cuMemAlloc/cuLaunchKernel to enforce them, plus a named
+// pipe for libvgpu.so to write usage samples to; nothing in the plugin reads
+// that pipe.
+package quota
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+// Quota describes the fractional share of a physical GPU a container is
+// allowed to consume.
+type Quota struct {
+	// MemoryBytes caps the device memory libvgpu.so will allow cuMemAlloc
+	// to hand out.
+	MemoryBytes int64 `json:"memoryBytes"`
+	// SMPercent caps the share of streaming multiprocessors libvgpu.so
+	// will allow cuLaunchKernel to use, 1-100.
+	SMPercent int `json:"smPercent"`
+}
+
+// config is the on-disk schema read by libvgpu.so.
+type config struct {
+	Quota Quota `json:"quota"`
+}
+
+const (
+	configDir = "/var/lib/kubelet/device-plugins/vgpu"
+
+	preloadLibHostPath      = "/usr/share/nvidia/vgpu/libvgpu.so"
+	preloadLibContainerPath = "/usr/local/lib/libvgpu.so"
+)
+
+// ConfigPath returns where the per-container quota config file for
+// containerID should be written.
+func ConfigPath(containerID string) string {
+	return filepath.Join(configDir, containerID+".json")
+}
+
+// UsageFIFOPath returns the path of the named pipe libvgpu.so writes usage
+// samples to. This package creates and mounts the pipe but does not read
+// from it.
+func UsageFIFOPath(containerID string) string {
+	return filepath.Join(configDir, containerID+".usage")
+}
+
+// WriteConfig writes the quota config for a container and creates the
+// usage-reporting FIFO libvgpu.so will write to.
+func WriteConfig(containerID string, q Quota) error {
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return fmt.Errorf("failed to create vgpu quota config directory: %v", err)
+	}
+	data, err := json.MarshalIndent(config{Quota: q}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal vgpu quota config: %v", err)
+	}
+	if err := os.WriteFile(ConfigPath(containerID), data, 0644); err != nil {
+		return fmt.Errorf("failed to write vgpu quota config: %v", err)
+	}
+	if err := syscall.Mkfifo(UsageFIFOPath(containerID), 0644); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("failed to create vgpu usage FIFO: %v", err)
+	}
+	return nil
+}
+
+// ValidateQuotas checks that the fractional requests sharing a single
+// physical GPU do not sum to more than 100% of its SM cores or more than
+// totalMemoryBytes of its HBM.
+func ValidateQuotas(physicalDeviceID string, quotas map[string]Quota, totalMemoryBytes int64) error {
+	var memory int64
+	var smPercent int
+	for _, q := range quotas {
+		memory += q.MemoryBytes
+		smPercent += q.SMPercent
+	}
+	if smPercent > 100 {
+		return fmt.Errorf("invalid vGPU quota request for %s, requested SM percentages sum to %d%%, want at most 100%%", physicalDeviceID, smPercent)
+	}
+	if memory > totalMemoryBytes {
+		return fmt.Errorf("invalid vGPU quota request for %s, requested memory %d bytes exceeds total device memory %d bytes", physicalDeviceID, memory, totalMemoryBytes)
+	}
+	return nil
+}
+
+// Allocate writes the quota config and FIFO for containerID and builds the
+// ContainerAllocateResponse that bind-mounts libvgpu.so and points it at
+// them via LD_PRELOAD.
+func Allocate(containerID string, q Quota) (*pluginapi.ContainerAllocateResponse, error) {
+	if err := WriteConfig(containerID, q); err != nil {
+		return nil, err
+	}
+	configPath, fifoPath := ConfigPath(containerID), UsageFIFOPath(containerID)
+	return &pluginapi.ContainerAllocateResponse{
+		Envs: map[string]string{
+			"LD_PRELOAD":       preloadLibContainerPath,
+			"VGPU_CONFIG_FILE": configPath,
+			"VGPU_USAGE_FIFO":  fifoPath,
+		},
+		Mounts: []*pluginapi.Mount{
+			{ContainerPath: preloadLibContainerPath, HostPath: preloadLibHostPath, ReadOnly: true},
+			{ContainerPath: configPath, HostPath: configPath, ReadOnly: true},
+			{ContainerPath: fifoPath, HostPath: fifoPath, ReadOnly: false},
+		},
+	}, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package quota
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateQuotas(t *testing.T) {
+	cases := []struct {
+		name             string
+		quotas           map[string]Quota
+		totalMemoryBytes int64
+		wantError        error
+	}{{
+		name: "within budget",
+		quotas: map[string]Quota{
+			"nvidia0/vgpu0": {MemoryBytes: 4 << 30, SMPercent: 25},
+			"nvidia0/vgpu1": {MemoryBytes: 4 << 30, SMPercent: 25},
+		},
+		totalMemoryBytes: 16 << 30,
+		wantError:        nil,
+	}, {
+		name: "SM percentage overcommitted",
+		quotas: map[string]Quota{
+			"nvidia0/vgpu0": {SMPercent: 60},
+			"nvidia0/vgpu1": {SMPercent: 60},
+		},
+		totalMemoryBytes: 16 << 30,
+		wantError:        errors.New("invalid vGPU quota request for nvidia0, requested SM percentages sum to 120%, want at most 100%"),
+	}, {
+		name: "memory overcommitted",
+		quotas: map[string]Quota{
+			"nvidia0/vgpu0": {MemoryBytes: 10 << 30},
+			"nvidia0/vgpu1": {MemoryBytes: 10 << 30},
+		},
+		totalMemoryBytes: 16 << 30,
+		wantError:        errors.New("invalid vGPU quota request for nvidia0, requested memory 21474836480 bytes exceeds total device memory 17179869184 bytes"),
+	}}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateQuotas("nvidia0", tc.quotas, tc.totalMemoryBytes)
+			if err != nil && tc.wantError != nil {
+				if err.Error() != tc.wantError.Error() {
+					t.Errorf("ValidateQuotas() error = %q, want %q", err.Error(), tc.wantError.Error())
+				}
+			} else if err != nil {
+				t.Error("unexpected error: ", err)
+			} else if tc.wantError != nil {
+				t.Error("unexpected want error:", tc.wantError)
+			}
+		})
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	// WriteConfig writes under a fixed system path, so this test only
+	// exercises the part that doesn't touch the filesystem.
+	if ConfigPath("abc") == "" {
+		t.Error("ConfigPath() returned empty path")
+	}
+	if UsageFIFOPath("abc") == ConfigPath("abc") {
+		t.Error("UsageFIFOPath() should not collide with ConfigPath()")
+	}
+}